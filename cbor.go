@@ -0,0 +1,348 @@
+package ordereddict
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborBreakErrorMsg is the (untyped) error the cbor library returns
+// when Decode() is asked for another item but instead finds the
+// "break" code that terminates an indefinite-length array or map.
+// The library doesn't expose a typed sentinel or a peek API for
+// this, so we match on the message it documents and tests against.
+const cborBreakErrorMsg = `unexpected "break" code`
+
+// MarshalCBOR emits the Dict's entries as a CBOR definite-length
+// map in self.items order, walking nested values by hand via
+// marshalCBORValue (see marshalValue) rather than the library's own
+// reflective Encode, so the same ancestor tracking as MarshalJSON
+// applies here too.
+func (self *Dict) MarshalCBOR() ([]byte, error) {
+	return self.marshalCBORInternal(newMarshalState())
+}
+
+func (self *Dict) marshalCBORInternal(state *marshalState) ([]byte, error) {
+	if state.ancestors[self] {
+		return nil, &CycleError{Dict: self}
+	}
+	state.ancestors[self] = true
+	defer delete(state.ancestors, self)
+
+	items := self.Items()
+
+	buf := &bytes.Buffer{}
+	encodeCBORHeader(buf, 5, uint64(len(items)))
+
+	for _, item := range items {
+		keyBytes, err := cbor.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyBytes)
+
+		vBytes, err := marshalCBORValue(item.Value, state)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vBytes)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalCBORValue encodes a single value, recursing into
+// []interface{} and map[string]interface{} values so that a *Dict
+// nested inside them still shares state and is covered by cycle
+// detection. Any other slice or map type goes to cbor.Marshal as a
+// whole, and a *Dict inside it does not share state. Maps and arrays are encoded with a
+// known-length header (see encodeCBORHeader) rather than the
+// library's indefinite-length Start.../EndIndefinite, since each
+// entry here is serialized independently (to recurse with `state`)
+// and spliced in as raw bytes, which the library has no way to keep
+// its own indefinite-length item count in sync with.
+func marshalCBORValue(value interface{}, state *marshalState) ([]byte, error) {
+	switch t := value.(type) {
+	case *Dict:
+		if t == nil {
+			return cbor.Marshal(nil)
+		}
+		return t.marshalCBORInternal(state)
+
+	case []interface{}:
+		buf := &bytes.Buffer{}
+		encodeCBORHeader(buf, 4, uint64(len(t)))
+		for _, item := range t {
+			itemBytes, err := marshalCBORValue(item, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(itemBytes)
+		}
+		return buf.Bytes(), nil
+
+	case map[string]interface{}:
+		buf := &bytes.Buffer{}
+		encodeCBORHeader(buf, 5, uint64(len(t)))
+		for k, v := range t {
+			keyBytes, err := cbor.Marshal(k)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(keyBytes)
+
+			vBytes, err := marshalCBORValue(v, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(vBytes)
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return cbor.Marshal(value)
+	}
+}
+
+// encodeCBORHeader writes a definite-length CBOR item header (major
+// type in the top 3 bits, argument-encoded count per RFC 8949 3.1) -
+// the mirror image of decodeCBORArgument on the decode side.
+func encodeCBORHeader(buf *bytes.Buffer, majorType byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(majorType<<5 | byte(n))
+
+	case n <= 0xff:
+		buf.WriteByte(majorType<<5 | 24)
+		buf.WriteByte(byte(n))
+
+	case n <= 0xffff:
+		buf.WriteByte(majorType<<5 | 25)
+		buf.WriteByte(byte(n >> 8))
+		buf.WriteByte(byte(n))
+
+	case n <= 0xffffffff:
+		buf.WriteByte(majorType<<5 | 26)
+		buf.WriteByte(byte(n >> 24))
+		buf.WriteByte(byte(n >> 16))
+		buf.WriteByte(byte(n >> 8))
+		buf.WriteByte(byte(n))
+
+	default:
+		buf.WriteByte(majorType<<5 | 27)
+		for i := 7; i >= 0; i-- {
+			buf.WriteByte(byte(n >> (uint(i) * 8)))
+		}
+	}
+}
+
+// UnmarshalCBOR implements cbor.Unmarshaler so a Dict can be decoded
+// directly with cbor.Unmarshal(data, dict). Unlike the library's own
+// generic decode (which lands maps in a Go map[string]interface{}
+// and loses key order), this walks the raw CBOR map by hand so
+// nested maps - at any depth - become order-preserving *Dicts via
+// set().
+func (self *Dict) UnmarshalCBOR(data []byte) error {
+	self.Lock()
+	defer self.Unlock()
+
+	if len(data) == 0 {
+		return fmt.Errorf("ordereddict: empty CBOR data")
+	}
+	if major := data[0] >> 5; major != 5 {
+		return fmt.Errorf("ordereddict: expected a CBOR map, got major type %d", major)
+	}
+
+	return self.parseCBORMap(data, 1)
+}
+
+// parseCBORMap decodes the CBOR map encoded in data (its own header
+// byte(s) included) into self, preserving key order. depth is the
+// nesting level of data itself and is checked against
+// SetMaxDecodeDepth the same way the JSON decoder does, so a
+// pathologically nested CBOR document can't exhaust the goroutine
+// stack either.
+func (self *Dict) parseCBORMap(data []byte, depth int) error {
+	if int64(depth) > atomic.LoadInt64(&maxDecodeDepth) {
+		return ErrMaxDepthExceeded
+	}
+
+	if data[0]&0x1f == 31 {
+		dec := cbor.NewDecoder(bytes.NewReader(data[1:]))
+		for {
+			key, value, err := decodeCBORMapEntry(dec, depth+1)
+			if err == errCBORBreak {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			self.set(key, value)
+		}
+	}
+
+	count, headerLen, err := decodeCBORArgument(data)
+	if err != nil {
+		return err
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(data[headerLen:]))
+	for i := uint64(0); i < count; i++ {
+		key, value, err := decodeCBORMapEntry(dec, depth+1)
+		if err != nil {
+			return err
+		}
+		self.set(key, value)
+	}
+
+	return nil
+}
+
+var errCBORBreak = fmt.Errorf("ordereddict: end of indefinite-length CBOR collection")
+
+func decodeCBORMapEntry(dec *cbor.Decoder, depth int) (key string, value interface{}, err error) {
+	var keyRaw cbor.RawMessage
+	if err = dec.Decode(&keyRaw); err != nil {
+		if strings.Contains(err.Error(), cborBreakErrorMsg) {
+			err = errCBORBreak
+		}
+		return
+	}
+	if err = cbor.Unmarshal(keyRaw, &key); err != nil {
+		return
+	}
+
+	var valueRaw cbor.RawMessage
+	if err = dec.Decode(&valueRaw); err != nil {
+		return
+	}
+	value, err = decodeCBORValue(valueRaw, depth)
+	return
+}
+
+// decodeCBORValue decodes a single raw CBOR item, recursing into
+// maps and arrays so that any *Dict nested at any depth preserves
+// its key order and any string promotes to a time.Time the same way
+// the JSON decoder does. depth is the nesting level of raw itself.
+func decodeCBORValue(raw cbor.RawMessage, depth int) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	switch raw[0] >> 5 {
+	case 5:
+		dict := NewDict()
+		if err := dict.parseCBORMap(raw, depth); err != nil {
+			return nil, err
+		}
+		return dict, nil
+
+	case 4:
+		return decodeCBORArray(raw, depth)
+
+	default:
+		var value interface{}
+		if err := cbor.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		if s, ok := value.(string); ok {
+			return maybeParseTimestamp(s), nil
+		}
+		return value, nil
+	}
+}
+
+func decodeCBORArray(raw cbor.RawMessage, depth int) ([]interface{}, error) {
+	if int64(depth) > atomic.LoadInt64(&maxDecodeDepth) {
+		return nil, ErrMaxDepthExceeded
+	}
+
+	result := []interface{}{}
+
+	if raw[0]&0x1f == 31 {
+		dec := cbor.NewDecoder(bytes.NewReader(raw[1:]))
+		for {
+			var itemRaw cbor.RawMessage
+			err := dec.Decode(&itemRaw)
+			if err != nil {
+				if strings.Contains(err.Error(), cborBreakErrorMsg) {
+					return result, nil
+				}
+				return nil, err
+			}
+			value, err := decodeCBORValue(itemRaw, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+	}
+
+	count, headerLen, err := decodeCBORArgument(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(raw[headerLen:]))
+	for i := uint64(0); i < count; i++ {
+		var itemRaw cbor.RawMessage
+		if err := dec.Decode(&itemRaw); err != nil {
+			return nil, err
+		}
+		value, err := decodeCBORValue(itemRaw, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+
+	return result, nil
+}
+
+// decodeCBORArgument parses the "argument" that follows a CBOR major
+// type's low 5 bits (RFC 8949 3.1) and returns its value together
+// with the number of header bytes consumed.
+func decodeCBORArgument(data []byte) (value uint64, headerLen int, err error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("ordereddict: truncated CBOR data")
+	}
+
+	additional := data[0] & 0x1f
+	switch {
+	case additional < 24:
+		return uint64(additional), 1, nil
+
+	case additional == 24:
+		if len(data) < 2 {
+			return 0, 0, fmt.Errorf("ordereddict: truncated CBOR data")
+		}
+		return uint64(data[1]), 2, nil
+
+	case additional == 25:
+		if len(data) < 3 {
+			return 0, 0, fmt.Errorf("ordereddict: truncated CBOR data")
+		}
+		return uint64(data[1])<<8 | uint64(data[2]), 3, nil
+
+	case additional == 26:
+		if len(data) < 5 {
+			return 0, 0, fmt.Errorf("ordereddict: truncated CBOR data")
+		}
+		return uint64(data[1])<<24 | uint64(data[2])<<16 | uint64(data[3])<<8 | uint64(data[4]), 5, nil
+
+	case additional == 27:
+		if len(data) < 9 {
+			return 0, 0, fmt.Errorf("ordereddict: truncated CBOR data")
+		}
+		var v uint64
+		for i := 1; i <= 8; i++ {
+			v = v<<8 | uint64(data[i])
+		}
+		return v, 9, nil
+
+	default:
+		return 0, 0, fmt.Errorf("ordereddict: unsupported CBOR length encoding")
+	}
+}
@@ -0,0 +1,244 @@
+package ordereddict
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Velocidex/json"
+)
+
+// Get retrieves key from d and converts it to T, covering the same
+// ground as GetString/GetBool/GetInt64/GetStrings but for any
+// requested type, so callers working with arbitrary decoded JSON
+// don't need a bespoke GetX method for every shape they care about.
+// It returns false if key is absent or the stored value can't be
+// converted to T.
+func Get[T any](d *Dict, key string) (T, bool) {
+	var zero T
+
+	value, pres := d.Get(key)
+	if !pres {
+		return zero, false
+	}
+
+	return convert[T](value)
+}
+
+// MustGet is like Get but panics if key is absent or cannot be
+// converted to T.
+func MustGet[T any](d *Dict, key string) T {
+	value, ok := Get[T](d, key)
+	if !ok {
+		panic(fmt.Sprintf("ordereddict: MustGet(%q) could not be converted", key))
+	}
+	return value
+}
+
+// GetOr is like Get but returns default_value instead of the zero
+// value when key is absent or cannot be converted to T.
+func GetOr[T any](d *Dict, key string, default_value T) T {
+	value, ok := Get[T](d, key)
+	if !ok {
+		return default_value
+	}
+	return value
+}
+
+// convert attempts to turn value into T, handling the same widening
+// rules as the existing GetX methods: numeric widening (including
+// json.Number), *string and []byte to string, and element-wise
+// conversion of a slice into []T.
+func convert[T any](value interface{}) (T, bool) {
+	var zero T
+
+	if v, ok := value.(T); ok {
+		return v, true
+	}
+
+	target := reflect.TypeOf(zero)
+	if target == nil {
+		// T is an interface type that the direct assertion above
+		// already would have matched had value implemented it.
+		return zero, false
+	}
+
+	converted, ok := convertValue(value, target)
+	if !ok {
+		return zero, false
+	}
+
+	result, ok := converted.Interface().(T)
+	if !ok {
+		return zero, false
+	}
+	return result, true
+}
+
+// convertValue is the reflection-based core of convert[T]: it can't
+// be generic over T itself because it recurses into slice element
+// types that are only known at runtime.
+func convertValue(value interface{}, target reflect.Type) (reflect.Value, bool) {
+	if value == nil {
+		return reflect.Value{}, false
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(target) {
+		return v, true
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		if s, ok := to_string(value); ok {
+			return reflect.ValueOf(s), true
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := convertToInt64(value); ok {
+			return reflect.ValueOf(n).Convert(target), true
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := convertToUint64(value); ok {
+			return reflect.ValueOf(n).Convert(target), true
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if f, ok := convertToFloat64(value); ok {
+			return reflect.ValueOf(f).Convert(target), true
+		}
+
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return reflect.ValueOf(b), true
+		}
+
+	case reflect.Slice:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return reflect.Value{}, false
+		}
+
+		elemType := target.Elem()
+		result := reflect.MakeSlice(target, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, ok := convertValue(v.Index(i).Interface(), elemType)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			result = reflect.Append(result, item)
+		}
+		return result, true
+	}
+
+	return reflect.Value{}, false
+}
+
+// convertToInt64 folds the widening logic GetInt64 has always used -
+// any Go integer or float type, plus json.Number - into int64.
+func convertToInt64(value interface{}) (int64, bool) {
+	switch t := value.(type) {
+	case int:
+		return int64(t), true
+	case int8:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case uint:
+		return int64(t), true
+	case uint8:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint64:
+		return int64(t), true
+	case float32:
+		return int64(t), true
+	case float64:
+		return int64(t), true
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, true
+		}
+		if f, err := t.Float64(); err == nil {
+			return int64(f), true
+		}
+	}
+	return 0, false
+}
+
+func convertToUint64(value interface{}) (uint64, bool) {
+	switch t := value.(type) {
+	case int:
+		return uint64(t), true
+	case int8:
+		return uint64(t), true
+	case int16:
+		return uint64(t), true
+	case int32:
+		return uint64(t), true
+	case int64:
+		return uint64(t), true
+	case uint:
+		return uint64(t), true
+	case uint8:
+		return uint64(t), true
+	case uint16:
+		return uint64(t), true
+	case uint32:
+		return uint64(t), true
+	case uint64:
+		return t, true
+	case float32:
+		return uint64(t), true
+	case float64:
+		return uint64(t), true
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return uint64(n), true
+		}
+		if f, err := t.Float64(); err == nil {
+			return uint64(f), true
+		}
+	}
+	return 0, false
+}
+
+func convertToFloat64(value interface{}) (float64, bool) {
+	switch t := value.(type) {
+	case int:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,37 @@
+package ordereddict
+
+import "testing"
+
+func TestGetConverts(t *testing.T) {
+	dict := NewDict().Set("n", float64(42)).Set("s", "hello").Set("items", []interface{}{1, 2, 3})
+
+	if n, ok := Get[int64](dict, "n"); !ok || n != 42 {
+		t.Fatalf("Get[int64](n) = (%v, %v), want (42, true)", n, ok)
+	}
+
+	if s, ok := Get[string](dict, "s"); !ok || s != "hello" {
+		t.Fatalf("Get[string](s) = (%q, %v), want (%q, true)", s, ok, "hello")
+	}
+
+	if items, ok := Get[[]int64](dict, "items"); !ok || len(items) != 3 || items[2] != 3 {
+		t.Fatalf("Get[[]int64](items) = (%v, %v), want ([1 2 3], true)", items, ok)
+	}
+
+	if _, ok := Get[int64](dict, "missing"); ok {
+		t.Fatalf("Get[int64](missing) should fail")
+	}
+}
+
+func TestGetOrMustGet(t *testing.T) {
+	dict := NewDict().Set("n", 7)
+
+	if got := GetOr(dict, "n", int64(0)); got != 7 {
+		t.Fatalf("GetOr(n) = %v, want 7", got)
+	}
+	if got := GetOr(dict, "missing", int64(99)); got != 99 {
+		t.Fatalf("GetOr(missing) = %v, want 99", got)
+	}
+	if got := MustGet[int64](dict, "n"); got != 7 {
+		t.Fatalf("MustGet(n) = %v, want 7", got)
+	}
+}
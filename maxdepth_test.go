@@ -0,0 +1,51 @@
+package ordereddict
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// nestedArrayJSON builds depth levels of nested JSON arrays, e.g.
+// nestedArrayJSON(3) == "[[[0]]]".
+func nestedArrayJSON(depth int) string {
+	return strings.Repeat("[", depth) + "0" + strings.Repeat("]", depth)
+}
+
+// nestedObjectJSON builds depth levels of nested JSON objects, e.g.
+// nestedObjectJSON(3) == `{"a":{"a":{"a":0}}}`.
+func nestedObjectJSON(depth int) string {
+	return strings.Repeat(`{"a":`, depth) + "0" + strings.Repeat("}", depth)
+}
+
+func TestUnmarshalJSONMaxDecodeDepth(t *testing.T) {
+	defer SetMaxDecodeDepth(10000)
+	SetMaxDecodeDepth(5)
+
+	dict := NewDict()
+	err := dict.UnmarshalJSON([]byte(`{"a":` + nestedArrayJSON(10) + `}`))
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+
+	dict = NewDict()
+	err = dict.UnmarshalJSON([]byte(nestedObjectJSON(10)))
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+}
+
+func TestUnmarshalJSONWithinMaxDecodeDepth(t *testing.T) {
+	defer SetMaxDecodeDepth(10000)
+	SetMaxDecodeDepth(5)
+
+	dict := NewDict()
+	if err := dict.UnmarshalJSON([]byte(`{"a":` + nestedArrayJSON(2) + `}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dict = NewDict()
+	if err := dict.UnmarshalJSON([]byte(nestedObjectJSON(2))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
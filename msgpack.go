@@ -0,0 +1,196 @@
+package ordereddict
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// MarshalMsgpack emits the Dict's entries as a MessagePack map in
+// self.items order, same as MarshalCBOR but for msgpack's wire
+// format.
+func (self *Dict) MarshalMsgpack() ([]byte, error) {
+	return self.marshalMsgpackInternal(newMarshalState())
+}
+
+func (self *Dict) marshalMsgpackInternal(state *marshalState) ([]byte, error) {
+	if state.ancestors[self] {
+		return nil, &CycleError{Dict: self}
+	}
+	state.ancestors[self] = true
+	defer delete(state.ancestors, self)
+
+	items := self.Items()
+
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+
+	if err := enc.EncodeMapLen(len(items)); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if err := enc.EncodeString(item.Key); err != nil {
+			return nil, err
+		}
+
+		vBytes, err := marshalMsgpackValue(item.Value, state)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vBytes)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalMsgpackValue is marshalCBORValue's msgpack counterpart.
+func marshalMsgpackValue(value interface{}, state *marshalState) ([]byte, error) {
+	switch t := value.(type) {
+	case *Dict:
+		if t == nil {
+			return msgpack.Marshal(nil)
+		}
+		return t.marshalMsgpackInternal(state)
+
+	case []interface{}:
+		buf := &bytes.Buffer{}
+		enc := msgpack.NewEncoder(buf)
+		if err := enc.EncodeArrayLen(len(t)); err != nil {
+			return nil, err
+		}
+		for _, item := range t {
+			itemBytes, err := marshalMsgpackValue(item, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(itemBytes)
+		}
+		return buf.Bytes(), nil
+
+	case map[string]interface{}:
+		buf := &bytes.Buffer{}
+		enc := msgpack.NewEncoder(buf)
+		if err := enc.EncodeMapLen(len(t)); err != nil {
+			return nil, err
+		}
+		for k, v := range t {
+			if err := enc.EncodeString(k); err != nil {
+				return nil, err
+			}
+			vBytes, err := marshalMsgpackValue(v, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(vBytes)
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return msgpack.Marshal(value)
+	}
+}
+
+// UnmarshalMsgpack implements msgpack.Unmarshaler so a Dict can be
+// decoded directly with msgpack.Unmarshal(data, dict). Unlike the
+// library's own generic decode (which lands maps in a Go
+// map[string]interface{} and loses key order), this walks the map by
+// hand via parseMsgpackMap/decodeMsgpackValue so nested maps - at any
+// depth, including inside arrays - become order-preserving *Dicts.
+func (self *Dict) UnmarshalMsgpack(data []byte) error {
+	self.Lock()
+	defer self.Unlock()
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+
+	return self.parseMsgpackMap(dec, n, 1)
+}
+
+// parseMsgpackMap decodes count key/value pairs from dec into self.
+// depth is the nesting level of this map itself and is checked
+// against SetMaxDecodeDepth the same way the JSON decoder does.
+func (self *Dict) parseMsgpackMap(dec *msgpack.Decoder, count int, depth int) error {
+	if int64(depth) > atomic.LoadInt64(&maxDecodeDepth) {
+		return ErrMaxDepthExceeded
+	}
+
+	for i := 0; i < count; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+
+		value, err := decodeMsgpackValue(dec, depth+1)
+		if err != nil {
+			return err
+		}
+
+		self.set(key, value)
+	}
+
+	return nil
+}
+
+// decodeMsgpackValue decodes a single MessagePack value from dec.
+// Maps and arrays are walked by hand (via PeekCode, mirroring the
+// CBOR decoder) rather than handed to the library's generic
+// DecodeInterface, so every level of nesting - map-in-map,
+// map-in-array, array-in-array - passes through the same depth
+// guard; only scalars fall back to DecodeInterface. Strings are
+// promoted to time.Time the same way the JSON decoder does.
+func decodeMsgpackValue(dec *msgpack.Decoder, depth int) (interface{}, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+		n, err := dec.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		dict := NewDict()
+		if err := dict.parseMsgpackMap(dec, n, depth); err != nil {
+			return nil, err
+		}
+		return dict, nil
+
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		if int64(depth) > atomic.LoadInt64(&maxDecodeDepth) {
+			return nil, ErrMaxDepthExceeded
+		}
+
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+
+		result := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			value, err := decodeMsgpackValue(dec, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		return result, nil
+
+	default:
+		value, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := value.(string); ok {
+			return maybeParseTimestamp(s), nil
+		}
+		return value, nil
+	}
+}
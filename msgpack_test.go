@@ -0,0 +1,74 @@
+package ordereddict
+
+import (
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	dict := NewDict().Set("a", 1).Set("b", "two").Set("c", []interface{}{1, 2, 3})
+	nested := NewDict().Set("x", "y")
+	dict.Set("nested", nested)
+
+	data, err := msgpack.Marshal(dict)
+	if err != nil {
+		t.Fatalf("MarshalMsgpack: %v", err)
+	}
+
+	out := NewDict()
+	if err := msgpack.Unmarshal(data, out); err != nil {
+		t.Fatalf("UnmarshalMsgpack: %v", err)
+	}
+
+	if got, _ := out.GetInt64("a"); got != 1 {
+		t.Fatalf("a = %v, want 1", got)
+	}
+	if got, _ := out.GetString("b"); got != "two" {
+		t.Fatalf("b = %q, want %q", got, "two")
+	}
+	if got, ok := out.Get("nested"); !ok {
+		t.Fatalf("nested missing")
+	} else if nd, ok := got.(*Dict); !ok {
+		t.Fatalf("nested = %T, want *Dict", got)
+	} else if s, _ := nd.GetString("x"); s != "y" {
+		t.Fatalf("nested.x = %q, want %q", s, "y")
+	}
+}
+
+func TestMarshalMsgpackCycle(t *testing.T) {
+	a := NewDict()
+	b := NewDict()
+	a.Set("b", b)
+	b.Set("a", a)
+
+	if _, err := a.MarshalMsgpack(); err == nil {
+		t.Fatalf("expected a CycleError, got nil")
+	} else if _, ok := err.(*CycleError); !ok {
+		t.Fatalf("expected *CycleError, got %T: %v", err, err)
+	}
+}
+
+func TestUnmarshalMsgpackMaxDecodeDepth(t *testing.T) {
+	defer SetMaxDecodeDepth(10000)
+
+	deep := NewDict()
+	cur := deep
+	for i := 0; i < 10; i++ {
+		child := NewDict()
+		cur.Set("a", child)
+		cur = child
+	}
+	cur.Set("a", "leaf")
+
+	data, err := deep.MarshalMsgpack()
+	if err != nil {
+		t.Fatalf("MarshalMsgpack: %v", err)
+	}
+
+	SetMaxDecodeDepth(5)
+	out := NewDict()
+	if err := msgpack.Unmarshal(data, out); err != ErrMaxDepthExceeded {
+		t.Fatalf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+}
@@ -2,12 +2,14 @@ package ordereddict
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Velocidex/json"
@@ -17,8 +19,24 @@ import (
 var (
 	// Mark the item as deleted
 	Deleted = 1
+
+	// ErrMaxDepthExceeded is returned by UnmarshalJSON when the input
+	// nests objects/arrays deeper than the limit configured with
+	// SetMaxDecodeDepth().
+	ErrMaxDepthExceeded = errors.New("ordereddict: maximum decode depth exceeded")
+
+	maxDecodeDepth int64 = 10000
 )
 
+// SetMaxDecodeDepth sets the maximum nesting depth of objects and
+// arrays that UnmarshalJSON will follow before giving up with
+// ErrMaxDepthExceeded, guarding against goroutine stack exhaustion
+// on pathological input such as "[[[[[...]]]]]" when decoding
+// untrusted JSON. The default is 10000.
+func SetMaxDecodeDepth(n int) {
+	atomic.StoreInt64(&maxDecodeDepth, int64(n))
+}
+
 type Item struct {
 	Key   string
 	Value interface{}
@@ -287,33 +305,10 @@ func (self *Dict) GetStrings(key string) ([]string, bool) {
 
 func (self *Dict) GetInt64(key string) (int64, bool) {
 	value, pres := self.Get(key)
-	if pres {
-		switch t := value.(type) {
-		case int:
-			return int64(t), true
-		case int8:
-			return int64(t), true
-		case int16:
-			return int64(t), true
-		case int32:
-			return int64(t), true
-		case int64:
-			return int64(t), true
-		case uint8:
-			return int64(t), true
-		case uint16:
-			return int64(t), true
-		case uint32:
-			return int64(t), true
-		case uint64:
-			return int64(t), true
-		case float32:
-			return int64(t), true
-		case float64:
-			return int64(t), true
-		}
+	if !pres {
+		return 0, false
 	}
-	return 0, false
+	return convertToInt64(value)
 }
 
 func (self *Dict) Keys() (res []string) {
@@ -404,10 +399,44 @@ func (self *Dict) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// DecoderOptions controls how the JSON decoder (UnmarshalJSON,
+// UnmarshalJSONWithOptions and the streaming Decoder) interprets
+// scalar values while parsing.
+type DecoderOptions struct {
+	// ParseTimestamps, when true, auto-promotes a string value that
+	// looks like a timestamp into a time.Time. This loses the
+	// original string on round-trip, so callers that need the exact
+	// source string back can disable it.
+	ParseTimestamps bool
+
+	// TimeLayouts are additional time.Parse layouts tried (after
+	// time.RFC3339) when ParseTimestamps is enabled, e.g.
+	// "2006-01-02" for bare dates.
+	TimeLayouts []string
+
+	// UseNumber keeps JSON numbers as json.Number instead of
+	// ordereddict's usual uint64/int64/float64 coercion.
+	UseNumber bool
+}
+
+// DefaultDecoderOptions returns the options used by UnmarshalJSON:
+// timestamp auto-promotion enabled, and numbers coerced to the first
+// of uint64, int64 or float64 that parses - this is the long-standing
+// backward compatible behavior.
+func DefaultDecoderOptions() DecoderOptions {
+	return DecoderOptions{ParseTimestamps: true}
+}
+
 // this implements type json.Unmarshaler interface, so can be called
 // in json.Unmarshal(data, om). We preserve key order when
 // unmarshaling from JSON.
 func (self *Dict) UnmarshalJSON(data []byte) error {
+	return self.UnmarshalJSONWithOptions(data, DefaultDecoderOptions())
+}
+
+// UnmarshalJSONWithOptions is like UnmarshalJSON but lets the caller
+// control timestamp auto-promotion and number handling via opts.
+func (self *Dict) UnmarshalJSONWithOptions(data []byte, opts DecoderOptions) error {
 	self.Lock()
 	defer self.Unlock()
 
@@ -424,7 +453,7 @@ func (self *Dict) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("expect JSON object open with '{'")
 	}
 
-	err = self.parseobject(dec)
+	err = self.parseobject(dec, decodeState{depth: 1, opts: opts})
 	if err != nil {
 		return err
 	}
@@ -437,7 +466,26 @@ func (self *Dict) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (self *Dict) parseobject(dec *json.Decoder) (err error) {
+// decodeState threads per-call configuration (DecoderOptions) and
+// the current nesting depth through parseobject/parsearray/
+// handledelim without a package-level global.
+type decodeState struct {
+	depth int
+	opts  DecoderOptions
+}
+
+// nested returns a copy of state one level deeper, used when
+// recursing into a child object or array.
+func (self decodeState) nested() decodeState {
+	self.depth++
+	return self
+}
+
+func (self *Dict) parseobject(dec *json.Decoder, state decodeState) (err error) {
+	if int64(state.depth) > atomic.LoadInt64(&maxDecodeDepth) {
+		return ErrMaxDepthExceeded
+	}
+
 	var t json.Token
 	for dec.More() {
 		t, err = dec.Token()
@@ -459,7 +507,7 @@ func (self *Dict) parseobject(dec *json.Decoder) (err error) {
 		}
 
 		var value interface{}
-		value, err = handledelim(t, dec)
+		value, err = handledelim(t, dec, state.nested())
 		if err != nil {
 			return err
 		}
@@ -481,7 +529,11 @@ func (self *Dict) parseobject(dec *json.Decoder) (err error) {
 	return nil
 }
 
-func parsearray(dec *json.Decoder) (arr []interface{}, err error) {
+func parsearray(dec *json.Decoder, state decodeState) (arr []interface{}, err error) {
+	if int64(state.depth) > atomic.LoadInt64(&maxDecodeDepth) {
+		return nil, ErrMaxDepthExceeded
+	}
+
 	var t json.Token
 	arr = make([]interface{}, 0)
 	for dec.More() {
@@ -491,7 +543,7 @@ func parsearray(dec *json.Decoder) (arr []interface{}, err error) {
 		}
 
 		var value interface{}
-		value, err = handledelim(t, dec)
+		value, err = handledelim(t, dec, state.nested())
 		if err != nil {
 			return
 		}
@@ -511,20 +563,59 @@ func parsearray(dec *json.Decoder) (arr []interface{}, err error) {
 	return
 }
 
-func handledelim(token json.Token, dec *json.Decoder) (res interface{}, err error) {
+// maybeParseTimestamp promotes a string that looks like an RFC3339
+// timestamp into a time.Time, mirroring the JSON decoder's default
+// auto-promotion so the other Dict codecs (MessagePack, CBOR) behave
+// the same way. Returns the original string unchanged when it is not
+// a timestamp.
+func maybeParseTimestamp(value string) interface{} {
+	return parseTimestampString(value, nil)
+}
+
+// parseTimestampString is the shared implementation behind
+// maybeParseTimestamp and the JSON decoder's DecoderOptions.
+// TimeLayouts: it always tries time.RFC3339 first, then falls back
+// to each layout in order. With no extra layouts it keeps the
+// original cheap length/position check (length >= 20 with 'T' at
+// position 10) to avoid calling time.Parse on every plain string;
+// with extra layouts (which may not look like RFC3339 at all, e.g.
+// "2006-01-02") that heuristic is skipped.
+func parseTimestampString(value string, layouts []string) interface{} {
+	if len(layouts) == 0 {
+		if len(value) < 20 || value[10] != 'T' {
+			return value
+		}
+		if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+			return parsed
+		}
+		return value
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed
+	}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+func handledelim(token json.Token, dec *json.Decoder, state decodeState) (res interface{}, err error) {
 	switch t := token.(type) {
 	case json.Delim:
 		switch t {
 		case '{':
 			dict2 := NewDict()
-			err = dict2.parseobject(dec)
+			err = dict2.parseobject(dec, state)
 			if err != nil {
 				return
 			}
 			return dict2, nil
 		case '[':
 			var value []interface{}
-			value, err = parsearray(dec)
+			value, err = parsearray(dec, state)
 			if err != nil {
 				return
 			}
@@ -534,18 +625,16 @@ func handledelim(token json.Token, dec *json.Decoder) (res interface{}, err erro
 		}
 
 	case string:
-		// does it look like a timestamp in RFC3339
-		if len(t) >= 20 && t[10] == 'T' {
-			// Attempt to convert it from timestamp.
-			parsed, err := time.Parse(time.RFC3339, t)
-			if err == nil {
-				return parsed, nil
-			}
+		if !state.opts.ParseTimestamps {
+			return t, nil
 		}
-
-		return t, nil
+		return parseTimestampString(t, state.opts.TimeLayouts), nil
 
 	case json.Number:
+		if state.opts.UseNumber {
+			return t, nil
+		}
+
 		value_str := t.String()
 
 		// Try to parse as Uint
@@ -570,8 +659,41 @@ func handledelim(token json.Token, dec *json.Decoder) (res interface{}, err erro
 	return token, nil
 }
 
+// CycleError is returned by MarshalJSON when a Dict (possibly
+// indirectly, e.g. through an intermediate slice or map) contains
+// itself.
+type CycleError struct {
+	Dict *Dict
+}
+
+func (self *CycleError) Error() string {
+	return fmt.Sprintf("ordereddict: cycle detected in Dict %p", self.Dict)
+}
+
+// marshalState tracks the Dicts currently being marshalled along the
+// path from the root, so we can detect a Dict that refers back to
+// one of its own ancestors - directly or through an intermediate
+// slice/map.
+type marshalState struct {
+	ancestors map[*Dict]bool
+}
+
+func newMarshalState() *marshalState {
+	return &marshalState{ancestors: make(map[*Dict]bool)}
+}
+
 // Preserve key order when marshalling to JSON.
 func (self *Dict) MarshalJSON() ([]byte, error) {
+	return self.marshalJSONInternal(newMarshalState())
+}
+
+func (self *Dict) marshalJSONInternal(state *marshalState) ([]byte, error) {
+	if state.ancestors[self] {
+		return nil, &CycleError{Dict: self}
+	}
+	state.ancestors[self] = true
+	defer delete(state.ancestors, self)
+
 	buf := &bytes.Buffer{}
 	buf.Write([]byte("{"))
 	for _, item := range self.Items() {
@@ -582,22 +704,25 @@ func (self *Dict) MarshalJSON() ([]byte, error) {
 			continue
 		}
 
-		// Check for back references and skip them - this is not perfect.
-		subdict, ok := item.Value.(*Dict)
-		if ok && subdict == self {
-			continue
-		}
-
 		buf.Write(kEscaped)
 		buf.Write([]byte(":"))
 
-		vBytes, err := json.Marshal(item.Value)
-		if err == nil {
-			buf.Write(vBytes)
-			buf.Write([]byte(","))
-		} else {
+		vBytes, err := marshalValue(item.Value, state)
+		if err != nil {
+			// A cycle anywhere below us makes the whole document
+			// unrepresentable - propagate it instead of silently
+			// dropping the cyclic value as null.
+			var cycleErr *CycleError
+			if errors.As(err, &cycleErr) {
+				return nil, err
+			}
+
 			buf.Write([]byte("null,"))
+			continue
 		}
+
+		buf.Write(vBytes)
+		buf.Write([]byte(","))
 	}
 	if len(self.items) > 0 {
 		buf.Truncate(buf.Len() - 1)
@@ -606,6 +731,66 @@ func (self *Dict) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// marshalValue marshals a single Dict value, recursing into
+// []interface{} and map[string]interface{} values so that a Dict
+// nested inside them still shares the marshalState and is covered by
+// cycle detection. Any other slice or map type goes to json.Marshal
+// as a whole, and a Dict inside it does not share the marshalState.
+func marshalValue(value interface{}, state *marshalState) ([]byte, error) {
+	switch t := value.(type) {
+	case *Dict:
+		if t == nil {
+			return []byte("null"), nil
+		}
+		return t.marshalJSONInternal(state)
+
+	case []interface{}:
+		buf := &bytes.Buffer{}
+		buf.WriteByte('[')
+		for i, item := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			itemBytes, err := marshalValue(item, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(itemBytes)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+
+	case map[string]interface{}:
+		buf := &bytes.Buffer{}
+		buf.WriteByte('{')
+		first := true
+		for k, v := range t {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+
+			kEscaped, err := json.Marshal(k)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(kEscaped)
+			buf.WriteByte(':')
+
+			vBytes, err := marshalValue(v, state)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(vBytes)
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+
+	default:
+		return json.Marshal(value)
+	}
+}
+
 func (self *Dict) MarshalYAML() (interface{}, error) {
 	result := yaml.MapSlice{}
 	for _, item := range self.Items() {
@@ -0,0 +1,104 @@
+package ordereddict
+
+import "strconv"
+
+// pathStep resolves a single path component against current, which
+// may be a *Dict (looked up by key) or a []interface{} (indexed when
+// component parses as an integer). It takes no lock of its own - it
+// only ever calls Dict's own thread-safe Get/Set/Delete, so walking
+// across several Dicts never holds more than one Dict's lock at a
+// time.
+func pathStep(current interface{}, component string) (interface{}, bool) {
+	switch t := current.(type) {
+	case *Dict:
+		if t == nil {
+			return nil, false
+		}
+		return t.Get(component)
+
+	case []interface{}:
+		idx, err := strconv.Atoi(component)
+		if err != nil || idx < 0 || idx >= len(t) {
+			return nil, false
+		}
+		return t[idx], true
+
+	default:
+		return nil, false
+	}
+}
+
+// GetPath walks path through nested *Dict values (indexing into a
+// []interface{} when a component parses as an integer) and returns
+// the value found at the end, e.g. GetPath("foo", "bar", "0", "baz").
+func (self *Dict) GetPath(path ...string) (interface{}, bool) {
+	var current interface{} = self
+
+	for _, component := range path {
+		value, ok := pathStep(current, component)
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+
+	return current, true
+}
+
+// GetPathString is a convenience wrapper around GetPath that
+// converts the result to a string the same way GetString does.
+func (self *Dict) GetPathString(path ...string) (string, bool) {
+	value, pres := self.GetPath(path...)
+	if !pres {
+		return "", false
+	}
+	return to_string(value)
+}
+
+// SetPath sets value at the nested path inside self, creating
+// intermediate *Dicts as needed (an existing non-Dict value at an
+// intermediate component is replaced with a fresh *Dict). It returns
+// self so calls can be chained like Set().
+func (self *Dict) SetPath(value interface{}, path ...string) *Dict {
+	if len(path) == 0 {
+		return self
+	}
+
+	dict := self
+	for _, component := range path[:len(path)-1] {
+		next, pres := dict.Get(component)
+		nextDict, ok := next.(*Dict)
+		if !pres || !ok || nextDict == nil {
+			nextDict = NewDict()
+			dict.Set(component, nextDict)
+		}
+		dict = nextDict
+	}
+
+	dict.Set(path[len(path)-1], value)
+	return self
+}
+
+// DeletePath removes the value at the nested path inside self, if it
+// exists. Intermediate components are resolved the same way as in
+// GetPath (by key in a *Dict, by index in a []interface{}); it is a
+// no-op if the path up to the last component does not resolve to a
+// non-nil *Dict.
+func (self *Dict) DeletePath(path ...string) {
+	if len(path) == 0 {
+		return
+	}
+
+	var current interface{} = self
+	for _, component := range path[:len(path)-1] {
+		value, ok := pathStep(current, component)
+		if !ok {
+			return
+		}
+		current = value
+	}
+
+	if dict, ok := current.(*Dict); ok && dict != nil {
+		dict.Delete(path[len(path)-1])
+	}
+}
@@ -0,0 +1,38 @@
+package ordereddict
+
+import "testing"
+
+func TestGetSetDeletePath(t *testing.T) {
+	dict := NewDict()
+	dict.SetPath("value", "a", "b", "c")
+
+	if got, ok := dict.GetPath("a", "b", "c"); !ok || got != "value" {
+		t.Fatalf("GetPath(a,b,c) = (%v, %v), want (%q, true)", got, ok, "value")
+	}
+
+	if got, ok := dict.GetPathString("a", "b", "c"); !ok || got != "value" {
+		t.Fatalf("GetPathString(a,b,c) = (%q, %v), want (%q, true)", got, ok, "value")
+	}
+
+	dict.DeletePath("a", "b", "c")
+	if _, ok := dict.GetPath("a", "b", "c"); ok {
+		t.Fatalf("GetPath(a,b,c) should be absent after DeletePath")
+	}
+}
+
+func TestPathNilDict(t *testing.T) {
+	dict := NewDict()
+	dict.Set("x", (*Dict)(nil))
+
+	if _, ok := dict.GetPath("x", "y"); ok {
+		t.Fatalf("GetPath through a nil *Dict should fail, not panic")
+	}
+
+	dict.SetPath("v", "x", "y")
+	if got, ok := dict.GetPath("x", "y"); !ok || got != "v" {
+		t.Fatalf("SetPath should replace the nil *Dict; GetPath(x,y) = (%v, %v)", got, ok)
+	}
+
+	dict.Set("z", (*Dict)(nil))
+	dict.DeletePath("z", "y")
+}
@@ -0,0 +1,92 @@
+package ordereddict
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Velocidex/json"
+)
+
+// Encoder writes a sequence of Dicts to an underlying io.Writer as
+// NDJSON / JSON-lines, one object per Encode() call, so a large
+// stream of records (e.g. Velociraptor result sets that can run into
+// the GB range) is never held in memory at once - only the record
+// currently being written is.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes dict to the underlying writer as a single JSON
+// object followed by a newline, so a sequence of Encode() calls
+// produces a valid NDJSON / JSON-lines stream. It shares
+// marshalJSONInternal/marshalValue with MarshalJSON(), so a cyclic
+// Dict returns a *CycleError here too instead of being silently
+// dropped or written as null.
+func (self *Encoder) Encode(dict *Dict) error {
+	data, err := dict.marshalJSONInternal(newMarshalState())
+	if err != nil {
+		return err
+	}
+
+	if _, err := self.w.Write(data); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(self.w, "\n")
+	return err
+}
+
+// Decoder reads a sequence of top-level JSON objects (e.g. NDJSON /
+// JSON-lines) from an underlying io.Reader, decoding each one into a
+// *Dict on demand rather than requiring the whole stream to be read
+// into memory first.
+type Decoder struct {
+	dec  *json.Decoder
+	opts DecoderOptions
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &Decoder{dec: dec, opts: DefaultDecoderOptions()}
+}
+
+// WithOptions configures how subsequent Decode() calls interpret
+// scalar values (timestamp promotion, number handling). It returns
+// self so it can be chained onto NewDecoder().
+func (self *Decoder) WithOptions(opts DecoderOptions) *Decoder {
+	self.opts = opts
+	return self
+}
+
+// Decode reads the next JSON object from the stream into a freshly
+// allocated *Dict. It returns io.EOF once the stream is exhausted.
+func (self *Decoder) Decode() (*Dict, error) {
+	if !self.dec.More() {
+		return nil, io.EOF
+	}
+
+	t, err := self.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := t.(json.Delim)
+	if !ok || delim != '{' {
+		return nil, fmt.Errorf("expect JSON object open with '{'")
+	}
+
+	dict := NewDict()
+	state := decodeState{depth: 1, opts: self.opts}
+	if err := dict.parseobject(self.dec, state); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
@@ -0,0 +1,57 @@
+package ordereddict
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	if err := enc.Encode(NewDict().Set("a", 1)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if err := enc.Encode(NewDict().Set("b", "two")); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	dec := NewDecoder(buf)
+
+	first, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, _ := first.GetInt64("a"); got != 1 {
+		t.Fatalf("a = %v, want 1", got)
+	}
+
+	second, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, _ := second.GetString("b"); got != "two" {
+		t.Fatalf("b = %q, want %q", got, "two")
+	}
+
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestEncodeCyclePropagatesError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	self := NewDict()
+	self.Set("self", self)
+
+	err := enc.Encode(self)
+	if err == nil {
+		t.Fatalf("expected a CycleError, got nil (wrote %q)", buf.String())
+	}
+	if _, ok := err.(*CycleError); !ok {
+		t.Fatalf("expected *CycleError, got %T: %v", err, err)
+	}
+}